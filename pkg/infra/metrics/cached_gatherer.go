@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// CachedGatherer is a prometheus.TransactionalGatherer for internal sources
+// that are expensive to compute on every scrape but change infrequently
+// (datasource inventory, plugin catalog, org/user counts, unified-storage
+// object counts, ...). Producers push a snapshot of metric families into
+// the gatherer out-of-band via StartUpdateSession, while scrapes read a
+// stable, previously committed view under an RLock.
+type CachedGatherer struct {
+	mu       sync.RWMutex
+	families map[uint64]*dto.MetricFamily
+	keys     map[uint64]string
+	sorted   []*dto.MetricFamily
+}
+
+// NewCachedGatherer returns an empty CachedGatherer.
+func NewCachedGatherer() *CachedGatherer {
+	return &CachedGatherer{
+		families: make(map[uint64]*dto.MetricFamily),
+		keys:     make(map[uint64]string),
+	}
+}
+
+// Gather implements prometheus.TransactionalGatherer. The returned done
+// func must be called once the caller is finished with the result.
+func (c *CachedGatherer) Gather() ([]*dto.MetricFamily, func(), error) {
+	c.mu.RLock()
+	return c.sorted, c.mu.RUnlock, nil
+}
+
+// metricKey hashes familyName plus the metric's sorted "name=value" label
+// pairs, returning both the hash and the canonical string it was derived
+// from, so callers can detect a genuine hash collision between two
+// distinct label sets.
+func metricKey(familyName string, labels []*dto.LabelPair) (uint64, string) {
+	pairs := make([]string, 0, len(labels))
+	for _, lp := range labels {
+		pairs = append(pairs, lp.GetName()+"="+lp.GetValue())
+	}
+	sort.Strings(pairs)
+
+	canonical := familyName + "{" + strings.Join(pairs, ",") + "}"
+	return xxhash.Sum64String(canonical), canonical
+}
+
+// Session stages inserts and deletes into a shadow map; the changes only
+// become visible to Gather once Commit is called.
+type Session struct {
+	c       *CachedGatherer
+	staged  map[uint64]*dto.MetricFamily
+	keys    map[uint64]string
+	deleted map[uint64]struct{}
+}
+
+// StartUpdateSession begins a new update session, seeded with the
+// gatherer's currently committed metrics.
+func (c *CachedGatherer) StartUpdateSession() *Session {
+	c.mu.RLock()
+	staged := make(map[uint64]*dto.MetricFamily, len(c.families))
+	keys := make(map[uint64]string, len(c.keys))
+	for k, v := range c.families {
+		staged[k] = v
+		keys[k] = c.keys[k]
+	}
+	c.mu.RUnlock()
+
+	return &Session{
+		c:       c,
+		staged:  staged,
+		keys:    keys,
+		deleted: make(map[uint64]struct{}),
+	}
+}
+
+// InsertInPlace stages metric, belonging to family, keyed by family name
+// and metric's sorted label set. A subsequent call with the same key
+// replaces the staged metric. It returns an error if the key's hash
+// collides with a different, already-staged label set.
+func (s *Session) InsertInPlace(family *dto.MetricFamily, metric *dto.Metric) error {
+	key, canonical := metricKey(family.GetName(), metric.GetLabel())
+	if existing, ok := s.keys[key]; ok && existing != canonical {
+		return fmt.Errorf("metrics: xxhash collision between metric keys %q and %q", canonical, existing)
+	}
+	s.keys[key] = canonical
+
+	name, help, typ := family.GetName(), family.GetHelp(), family.GetType()
+	s.staged[key] = &dto.MetricFamily{
+		Name:   &name,
+		Help:   &help,
+		Type:   &typ,
+		Metric: []*dto.Metric{metric},
+	}
+	delete(s.deleted, key)
+	return nil
+}
+
+// Delete stages the removal of the metric identified by familyName and
+// labels.
+func (s *Session) Delete(familyName string, labels ...*dto.LabelPair) {
+	key, _ := metricKey(familyName, labels)
+	s.deleted[key] = struct{}{}
+	delete(s.staged, key)
+	delete(s.keys, key)
+}
+
+// Commit atomically swaps the staged changes into the live cache. The
+// sorted slice it builds is never mutated in place afterwards: a future
+// Commit always starts a fresh merge, so concurrent readers holding onto a
+// previously returned slice are unaffected.
+func (s *Session) Commit() error {
+	merged := make(map[string]*dto.MetricFamily)
+	order := make([]string, 0, len(s.staged))
+	for key := range s.staged {
+		if _, deleted := s.deleted[key]; deleted {
+			continue
+		}
+		fam := s.staged[key]
+		name := fam.GetName()
+
+		out, ok := merged[name]
+		if !ok {
+			out = &dto.MetricFamily{Name: fam.Name, Help: fam.Help, Type: fam.Type}
+			merged[name] = out
+			order = append(order, name)
+		} else if out.GetHelp() != fam.GetHelp() || out.GetType() != fam.GetType() {
+			return fmt.Errorf("metrics: family %q staged with inconsistent help/type (%q/%s vs %q/%s)",
+				name, out.GetHelp(), out.GetType(), fam.GetHelp(), fam.GetType())
+		}
+		out.Metric = append(out.Metric, fam.Metric...)
+	}
+
+	sorted := make([]*dto.MetricFamily, 0, len(order))
+	for _, name := range order {
+		sorted = append(sorted, merged[name])
+	}
+	sortFamilies(sorted)
+
+	s.c.mu.Lock()
+	s.c.families = s.staged
+	s.c.keys = s.keys
+	s.c.sorted = sorted
+	s.c.mu.Unlock()
+	return nil
+}
+
+// AsGatherer adapts a prometheus.TransactionalGatherer to a plain
+// prometheus.Gatherer, so it can be registered with MultiGatherer
+// implementations, which only know about the Gatherer interface.
+func AsGatherer(tg prometheus.TransactionalGatherer) prometheus.Gatherer {
+	return transactionalGathererAdapter{tg}
+}
+
+type transactionalGathererAdapter struct {
+	tg prometheus.TransactionalGatherer
+}
+
+func (a transactionalGathererAdapter) Gather() ([]*dto.MetricFamily, error) {
+	mf, done, err := a.tg.Gather()
+	defer done()
+	return mf, err
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"regexp"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// ExclusionConfig lists metric families that PrefixGatherer must pass
+// through verbatim, instead of prefixing them with a sub-gatherer's name.
+// It exists for standard, externally-meaningful families such as "up" or
+// the Prometheus client's own process_*/scrape_* conventions, which must
+// keep their well-known names regardless of which sub-gatherer collected
+// them.
+type ExclusionConfig struct {
+	// ExactNames lists metric family names to pass through unchanged.
+	ExactNames []string
+	// Patterns additionally passes through any family name matching one
+	// of these regexes.
+	Patterns []*regexp.Regexp
+
+	// StripConstLabels, if true, removes ConstLabelNames from passthrough
+	// families before they are exported.
+	StripConstLabels bool
+	// ConstLabelNames are the names of any ConstLabels an operator has
+	// wrapped the underlying registerer with (prometheus.WrapRegistererWith)
+	// that should not leak onto passthrough families such as "up". Only
+	// consulted when StripConstLabels is true.
+	ConstLabelNames []string
+}
+
+// DefaultExclusionConfig passes through the "up" metric and the
+// process_*/scrape_* families Prometheus client libraries conventionally
+// emit.
+func DefaultExclusionConfig() ExclusionConfig {
+	return ExclusionConfig{
+		ExactNames: []string{"up"},
+		Patterns: []*regexp.Regexp{
+			regexp.MustCompile(`^process_`),
+			regexp.MustCompile(`^scrape_`),
+		},
+	}
+}
+
+func (e ExclusionConfig) matches(familyName string) bool {
+	for _, n := range e.ExactNames {
+		if n == familyName {
+			return true
+		}
+	}
+	for _, re := range e.Patterns {
+		if re.MatchString(familyName) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripLabels returns a copy of fam with any label in names removed from
+// every metric. fam itself is left untouched, since it may still be
+// referenced by a previously returned, immutable Gather() result.
+func stripLabels(fam *dto.MetricFamily, names []string) *dto.MetricFamily {
+	if len(names) == 0 {
+		return fam
+	}
+	drop := make(map[string]struct{}, len(names))
+	for _, n := range names {
+		drop[n] = struct{}{}
+	}
+
+	out := &dto.MetricFamily{Name: fam.Name, Help: fam.Help, Type: fam.Type}
+	for _, m := range fam.GetMetric() {
+		nm := &dto.Metric{
+			TimestampMs: m.TimestampMs,
+			Counter:     m.Counter,
+			Gauge:       m.Gauge,
+			Untyped:     m.Untyped,
+			Summary:     m.Summary,
+			Histogram:   m.Histogram,
+		}
+		for _, lp := range m.GetLabel() {
+			if _, ok := drop[lp.GetName()]; ok {
+				continue
+			}
+			nm.Label = append(nm.Label, lp)
+		}
+		out.Metric = append(out.Metric, nm)
+	}
+	return out
+}
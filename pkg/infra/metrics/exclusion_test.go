@@ -0,0 +1,134 @@
+package metrics
+
+import (
+	"regexp"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestExclusionConfigMatches(t *testing.T) {
+	excl := ExclusionConfig{
+		ExactNames: []string{"up"},
+		Patterns:   []*regexp.Regexp{regexp.MustCompile(`^process_`)},
+	}
+
+	cases := map[string]bool{
+		"up":                 true,
+		"process_cpu_total":  true,
+		"scrape_duration_ns": false,
+		"requests_total":     false,
+	}
+	for name, want := range cases {
+		if got := excl.matches(name); got != want {
+			t.Errorf("matches(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestDefaultExclusionConfigCoversConventionalFamilies(t *testing.T) {
+	excl := DefaultExclusionConfig()
+	for _, name := range []string{"up", "process_resident_memory_bytes", "scrape_duration_seconds"} {
+		if !excl.matches(name) {
+			t.Errorf("DefaultExclusionConfig: expected %q to pass through", name)
+		}
+	}
+	if excl.matches("grafana_requests_total") {
+		t.Error(`DefaultExclusionConfig: did not expect "grafana_requests_total" to pass through`)
+	}
+}
+
+func TestStripLabelsRemovesOnlyNamedLabelsWithoutMutatingInput(t *testing.T) {
+	name, help := "up", "up help"
+	typ := dto.MetricType_GAUGE
+	value := 1.0
+	keep, keepVal := "job", "grafana"
+	drop, dropVal := "instance_name", "prod-1"
+
+	fam := &dto.MetricFamily{
+		Name: &name,
+		Help: &help,
+		Type: &typ,
+		Metric: []*dto.Metric{{
+			Gauge: &dto.Gauge{Value: &value},
+			Label: []*dto.LabelPair{
+				{Name: &keep, Value: &keepVal},
+				{Name: &drop, Value: &dropVal},
+			},
+		}},
+	}
+
+	out := stripLabels(fam, []string{"instance_name"})
+
+	if got, want := len(fam.GetMetric()[0].GetLabel()), 2; got != want {
+		t.Fatalf("stripLabels mutated its input: fam now has %d labels, want %d", got, want)
+	}
+
+	if got, want := len(out.GetMetric()), 1; got != want {
+		t.Fatalf("stripped family has %d metrics, want %d", got, want)
+	}
+	labels := out.GetMetric()[0].GetLabel()
+	if got, want := len(labels), 1; got != want {
+		t.Fatalf("stripped metric has %d labels, want %d", got, want)
+	}
+	if got, want := labels[0].GetName(), keep; got != want {
+		t.Errorf("stripped metric kept label %q, want %q", got, want)
+	}
+}
+
+func TestStripLabelsNoOpWhenNoNamesGiven(t *testing.T) {
+	fam, _ := newCounterFamily("up", 1)
+	if out := stripLabels(fam, nil); out != fam {
+		t.Error("stripLabels with no names should return fam unchanged")
+	}
+}
+
+// TestPrefixGathererStripsConstLabelsFromPassthroughFamilies is an
+// end-to-end check that StripConstLabels/ConstLabelNames actually reaches
+// passthrough families gathered through PrefixGatherer, not just the
+// stripLabels helper in isolation.
+func TestPrefixGathererStripsConstLabelsFromPassthroughFamilies(t *testing.T) {
+	name, help := "up", "up help"
+	typ := dto.MetricType_GAUGE
+	value := 1.0
+	constLabel, constVal := "instance_name", "prod-1"
+
+	sub := gathererFunc(func() ([]*dto.MetricFamily, error) {
+		return []*dto.MetricFamily{{
+			Name: &name,
+			Help: &help,
+			Type: &typ,
+			Metric: []*dto.Metric{{
+				Gauge: &dto.Gauge{Value: &value},
+				Label: []*dto.LabelPair{{Name: &constLabel, Value: &constVal}},
+			}},
+		}}, nil
+	})
+
+	excl := DefaultExclusionConfig()
+	excl.StripConstLabels = true
+	excl.ConstLabelNames = []string{"instance_name"}
+
+	p := NewPrefixGatherer().WithExclusions(excl)
+	if err := p.Register("sub", sub); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	mf, err := p.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+
+	var up *dto.MetricFamily
+	for _, fam := range mf {
+		if fam.GetName() == "up" {
+			up = fam
+		}
+	}
+	if up == nil {
+		t.Fatal(`expected an "up" family in the gathered output`)
+	}
+	if got := up.GetMetric()[0].GetLabel(); len(got) != 0 {
+		t.Errorf("expected instance_name to be stripped, got labels %v", got)
+	}
+}
@@ -0,0 +1,237 @@
+package metrics
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// MultiGatherer composes metrics from a set of named, independently owned
+// prometheus.Gatherers into a single exported output. Each sub-gatherer is
+// free to register its own metrics without knowing anything about the
+// top-level registry it ends up part of, or about any of the other
+// sub-gatherers composed alongside it.
+type MultiGatherer interface {
+	prometheus.Gatherer
+
+	// Register adds a named sub-gatherer. It returns an error if name is
+	// already registered, or if g's metrics would collide with a family
+	// already emitted by a previously registered sub-gatherer.
+	Register(name string, g prometheus.Gatherer) error
+}
+
+type namedGatherer struct {
+	name string
+	g    prometheus.Gatherer
+}
+
+// legacyPassthroughFamilies preserves the behaviour of the addPrefixWrapper
+// it replaces: metrics already carrying the "grafana_" or "go_" prefix are
+// never renamed, regardless of which sub-gatherer they came from.
+var legacyPassthroughFamilies = regexp.MustCompile("^(grafana_|go_)")
+
+func sortFamilies(mf []*dto.MetricFamily) {
+	sort.Slice(mf, func(i, j int) bool { return mf[i].GetName() < mf[j].GetName() })
+}
+
+func duplicateFamilyCheck(mf []*dto.MetricFamily) error {
+	seen := make(map[string]struct{}, len(mf))
+	for _, fam := range mf {
+		name := fam.GetName()
+		if _, exists := seen[name]; exists {
+			return fmt.Errorf("metrics: duplicate metric family %q across sub-gatherers", name)
+		}
+		seen[name] = struct{}{}
+	}
+	return nil
+}
+
+// PrefixGatherer composes sub-gatherers by prepending "<name>_" to every
+// metric family a sub-gatherer emits, unless the family already carries
+// that prefix or is excluded from renaming by Exclusions.
+type PrefixGatherer struct {
+	gatherers []namedGatherer
+	known     map[string]struct{}
+	excl      ExclusionConfig
+}
+
+// NewPrefixGatherer returns an empty PrefixGatherer, ready to have
+// sub-gatherers Register-ed with it.
+func NewPrefixGatherer() *PrefixGatherer {
+	return &PrefixGatherer{known: make(map[string]struct{})}
+}
+
+// WithExclusions configures families that are passed through verbatim
+// instead of being prefixed, e.g. "up" or process_*/scrape_* families
+// collected from an external source. It must be called before any
+// Register, since exclusions affect collision detection.
+func (p *PrefixGatherer) WithExclusions(excl ExclusionConfig) *PrefixGatherer {
+	p.excl = excl
+	return p
+}
+
+func (p *PrefixGatherer) prefixedName(name, familyName string) string {
+	if p.excl.matches(familyName) {
+		return familyName
+	}
+	prefix := name + "_"
+	if strings.HasPrefix(familyName, prefix) || legacyPassthroughFamilies.MatchString(familyName) {
+		return familyName
+	}
+	return prefix + familyName
+}
+
+func (p *PrefixGatherer) Register(name string, g prometheus.Gatherer) error {
+	for _, ng := range p.gatherers {
+		if ng.name == name {
+			return fmt.Errorf("metrics: gatherer %q already registered", name)
+		}
+	}
+
+	mf, err := g.Gather()
+	if err != nil {
+		return fmt.Errorf("metrics: failed to gather from %q: %w", name, err)
+	}
+
+	produced := make(map[string]struct{}, len(mf))
+	for _, fam := range mf {
+		prefixed := p.prefixedName(name, fam.GetName())
+		if _, exists := p.known[prefixed]; exists {
+			return fmt.Errorf("metrics: gatherer %q would emit metric family %q, which is already emitted by another sub-gatherer", name, prefixed)
+		}
+		produced[prefixed] = struct{}{}
+	}
+
+	for n := range produced {
+		p.known[n] = struct{}{}
+	}
+	p.gatherers = append(p.gatherers, namedGatherer{name: name, g: g})
+	return nil
+}
+
+func (p *PrefixGatherer) Gather() ([]*dto.MetricFamily, error) {
+	out := make([]*dto.MetricFamily, 0)
+	for _, ng := range p.gatherers {
+		mf, err := ng.g.Gather()
+		if err != nil {
+			return nil, fmt.Errorf("metrics: failed to gather from %q: %w", ng.name, err)
+		}
+		for _, fam := range mf {
+			newName := p.prefixedName(ng.name, fam.GetName())
+			switch {
+			case newName == fam.GetName() && p.excl.matches(fam.GetName()) && p.excl.StripConstLabels:
+				fam = stripLabels(fam, p.excl.ConstLabelNames)
+			case newName != fam.GetName():
+				// fam may be a sub-gatherer's own, still-referenced
+				// state (e.g. CachedGatherer's committed snapshot), so
+				// clone rather than rename *fam.Name in place.
+				fam = &dto.MetricFamily{Name: &newName, Help: fam.Help, Type: fam.Type, Metric: fam.Metric}
+			}
+			out = append(out, fam)
+		}
+	}
+
+	sortFamilies(out)
+	if err := duplicateFamilyCheck(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// sourceLabelName is the constant label LabelGatherer injects into every
+// series it composes, to identify which sub-gatherer produced it.
+const sourceLabelName = "source"
+
+// LabelGatherer composes sub-gatherers by adding a constant
+// source="<name>" label to every series a sub-gatherer emits, leaving
+// metric family names untouched.
+type LabelGatherer struct {
+	gatherers []namedGatherer
+}
+
+// NewLabelGatherer returns an empty LabelGatherer, ready to have
+// sub-gatherers Register-ed with it.
+func NewLabelGatherer() *LabelGatherer {
+	return &LabelGatherer{}
+}
+
+func (l *LabelGatherer) Register(name string, g prometheus.Gatherer) error {
+	for _, ng := range l.gatherers {
+		if ng.name == name {
+			return fmt.Errorf("metrics: gatherer %q already registered", name)
+		}
+	}
+
+	mf, err := g.Gather()
+	if err != nil {
+		return fmt.Errorf("metrics: failed to gather from %q: %w", name, err)
+	}
+	for _, fam := range mf {
+		for _, m := range fam.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() == sourceLabelName {
+					return fmt.Errorf("metrics: gatherer %q already has a %q label, cannot be composed by LabelGatherer", name, sourceLabelName)
+				}
+			}
+		}
+	}
+
+	l.gatherers = append(l.gatherers, namedGatherer{name: name, g: g})
+	return nil
+}
+
+func (l *LabelGatherer) Gather() ([]*dto.MetricFamily, error) {
+	// Unlike PrefixGatherer, two sub-gatherers are expected to share a
+	// family name here: disambiguating same-named series via the
+	// injected source label, rather than renaming, is the whole point of
+	// LabelGatherer. So families sharing a name are merged into one
+	// instead of being rejected as duplicates.
+	merged := make(map[string]*dto.MetricFamily)
+	order := make([]string, 0)
+
+	for _, ng := range l.gatherers {
+		mf, err := ng.g.Gather()
+		if err != nil {
+			return nil, fmt.Errorf("metrics: failed to gather from %q: %w", ng.name, err)
+		}
+		source := ng.name
+		for _, fam := range mf {
+			name := fam.GetName()
+
+			out, ok := merged[name]
+			if !ok {
+				out = &dto.MetricFamily{Name: fam.Name, Help: fam.Help, Type: fam.Type}
+				merged[name] = out
+				order = append(order, name)
+			} else if out.GetHelp() != fam.GetHelp() || out.GetType() != fam.GetType() {
+				return nil, fmt.Errorf("metrics: family %q gathered from %q with inconsistent help/type (%q/%s vs %q/%s)",
+					name, ng.name, out.GetHelp(), out.GetType(), fam.GetHelp(), fam.GetType())
+			}
+
+			// fam/its metrics may be a sub-gatherer's own, still-referenced
+			// state (e.g. CachedGatherer's committed snapshot), so clone
+			// rather than append to m.Label in place.
+			for _, m := range fam.GetMetric() {
+				labels := make([]*dto.LabelPair, 0, len(m.GetLabel())+1)
+				labels = append(labels, m.GetLabel()...)
+				key, value := sourceLabelName, source
+				labels = append(labels, &dto.LabelPair{Name: &key, Value: &value})
+
+				clone := *m
+				clone.Label = labels
+				out.Metric = append(out.Metric, &clone)
+			}
+		}
+	}
+
+	out := make([]*dto.MetricFamily, 0, len(order))
+	for _, name := range order {
+		out = append(out, merged[name])
+	}
+	sortFamilies(out)
+	return out, nil
+}
@@ -0,0 +1,219 @@
+package metrics
+
+import (
+	"sync"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// gathererFunc adapts a plain function to a prometheus.Gatherer, for
+// tests that need a fixed set of families without a real collector.
+type gathererFunc func() ([]*dto.MetricFamily, error)
+
+func (f gathererFunc) Gather() ([]*dto.MetricFamily, error) { return f() }
+
+// TestPrefixGathererConcurrentGatherDoesNotMutateSharedFamilies guards
+// against the renaming path in PrefixGatherer.Gather mutating a
+// sub-gatherer's returned families in place: it wraps a CachedGatherer,
+// whose committed snapshot is shared across every Gather call, and runs
+// many concurrent gathers alongside commits under the race detector.
+func TestPrefixGathererConcurrentGatherDoesNotMutateSharedFamilies(t *testing.T) {
+	cache := NewCachedGatherer()
+	s := cache.StartUpdateSession()
+	fam, metric := newCounterFamily("requests", 1)
+	if err := s.InsertInPlace(fam, metric); err != nil {
+		t.Fatalf("InsertInPlace: %v", err)
+	}
+	if err := s.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	p := NewPrefixGatherer()
+	if err := p.Register("sub", AsGatherer(cache)); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	const gathers = 8
+	const commits = 25
+
+	var commitWG, gatherWG sync.WaitGroup
+	stop := make(chan struct{})
+
+	commitWG.Add(1)
+	go func() {
+		defer commitWG.Done()
+		for i := 0; i < commits; i++ {
+			s := cache.StartUpdateSession()
+			fam, metric := newCounterFamily("requests", float64(i))
+			if err := s.InsertInPlace(fam, metric); err != nil {
+				t.Errorf("InsertInPlace: %v", err)
+				return
+			}
+			if err := s.Commit(); err != nil {
+				t.Errorf("Commit: %v", err)
+				return
+			}
+		}
+	}()
+
+	for g := 0; g < gathers; g++ {
+		gatherWG.Add(1)
+		go func() {
+			defer gatherWG.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				mf, err := p.Gather()
+				if err != nil {
+					t.Errorf("Gather: %v", err)
+					return
+				}
+				for _, f := range mf {
+					if got, want := f.GetName(), "sub_requests"; got != want {
+						t.Errorf("Gather: family name = %q, want %q", got, want)
+					}
+				}
+			}
+		}()
+	}
+
+	commitWG.Wait()
+	close(stop)
+	gatherWG.Wait()
+}
+
+// TestPrefixGathererPrefixesAndExcludes exercises the non-concurrent
+// prefixing/exclusion rules that the renaming fix must preserve.
+func TestPrefixGathererPrefixesAndExcludes(t *testing.T) {
+	cache := NewCachedGatherer()
+	s := cache.StartUpdateSession()
+	upFam, upMetric := newCounterFamily("up", 1)
+	reqFam, reqMetric := newCounterFamily("requests", 1)
+	if err := s.InsertInPlace(upFam, upMetric); err != nil {
+		t.Fatalf("InsertInPlace: %v", err)
+	}
+	if err := s.InsertInPlace(reqFam, reqMetric); err != nil {
+		t.Fatalf("InsertInPlace: %v", err)
+	}
+	if err := s.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	p := NewPrefixGatherer().WithExclusions(DefaultExclusionConfig())
+	if err := p.Register("sub", AsGatherer(cache)); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	mf, err := p.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+
+	names := make(map[string]bool, len(mf))
+	for _, fam := range mf {
+		names[fam.GetName()] = true
+	}
+	if !names["up"] {
+		t.Error(`expected "up" to pass through unprefixed`)
+	}
+	if !names["sub_requests"] {
+		t.Error(`expected "requests" to be prefixed to "sub_requests"`)
+	}
+
+	// The original snapshot held by the cache must be untouched by the
+	// rename above.
+	cached, done, err := cache.Gather()
+	if err != nil {
+		t.Fatalf("cache.Gather: %v", err)
+	}
+	defer done()
+	for _, fam := range cached {
+		if fam.GetName() == "sub_requests" {
+			t.Errorf("cache's own snapshot was renamed to %q, the family name was mutated in place", fam.GetName())
+		}
+	}
+}
+
+// TestLabelGathererMergesSharedFamilyNames checks that two sub-gatherers
+// emitting the same family name are merged into one family with both
+// metrics, disambiguated by the injected source label, instead of failing
+// the whole scrape as a duplicate.
+func TestLabelGathererMergesSharedFamilyNames(t *testing.T) {
+	l := NewLabelGatherer()
+
+	oneFam, oneMetric := newCounterFamily("requests", 1)
+	if err := l.Register("one", gathererFunc(func() ([]*dto.MetricFamily, error) {
+		return []*dto.MetricFamily{{Name: oneFam.Name, Help: oneFam.Help, Type: oneFam.Type, Metric: []*dto.Metric{oneMetric}}}, nil
+	})); err != nil {
+		t.Fatalf("Register(one): %v", err)
+	}
+
+	twoFam, twoMetric := newCounterFamily("requests", 2)
+	if err := l.Register("two", gathererFunc(func() ([]*dto.MetricFamily, error) {
+		return []*dto.MetricFamily{{Name: twoFam.Name, Help: twoFam.Help, Type: twoFam.Type, Metric: []*dto.Metric{twoMetric}}}, nil
+	})); err != nil {
+		t.Fatalf("Register(two): %v", err)
+	}
+
+	mf, err := l.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+
+	var found *dto.MetricFamily
+	for _, fam := range mf {
+		if fam.GetName() == "requests" {
+			found = fam
+		}
+	}
+	if found == nil {
+		t.Fatal(`expected a single merged "requests" family`)
+	}
+	if got, want := len(found.GetMetric()), 2; got != want {
+		t.Fatalf("merged family has %d metrics, want %d", got, want)
+	}
+
+	sources := make(map[string]bool, 2)
+	for _, m := range found.GetMetric() {
+		for _, lp := range m.GetLabel() {
+			if lp.GetName() == sourceLabelName {
+				sources[lp.GetValue()] = true
+			}
+		}
+	}
+	if !sources["one"] || !sources["two"] {
+		t.Errorf("merged metrics missing expected source labels, got %v", sources)
+	}
+}
+
+// TestLabelGathererRejectsMismatchedHelpType checks that two sub-gatherers
+// sharing a family name but disagreeing on Help/Type fail Gather instead
+// of silently merging into a type-mismatched family.
+func TestLabelGathererRejectsMismatchedHelpType(t *testing.T) {
+	l := NewLabelGatherer()
+
+	counterFam, counterMetric := newCounterFamily("requests", 1)
+	if err := l.Register("one", gathererFunc(func() ([]*dto.MetricFamily, error) {
+		return []*dto.MetricFamily{{Name: counterFam.Name, Help: counterFam.Help, Type: counterFam.Type, Metric: []*dto.Metric{counterMetric}}}, nil
+	})); err != nil {
+		t.Fatalf("Register(one): %v", err)
+	}
+
+	name, help := "requests", "a different meaning entirely"
+	gaugeType := dto.MetricType_GAUGE
+	value := 1.0
+	gaugeMetric := &dto.Metric{Gauge: &dto.Gauge{Value: &value}}
+	if err := l.Register("two", gathererFunc(func() ([]*dto.MetricFamily, error) {
+		return []*dto.MetricFamily{{Name: &name, Help: &help, Type: &gaugeType, Metric: []*dto.Metric{gaugeMetric}}}, nil
+	})); err != nil {
+		t.Fatalf("Register(two): %v", err)
+	}
+
+	if _, err := l.Gather(); err == nil {
+		t.Fatal("expected Gather to reject families sharing a name with inconsistent help/type, got nil error")
+	}
+}
@@ -0,0 +1,226 @@
+package otlpbridge
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+const (
+	defaultInterval = time.Minute
+	defaultTimeout  = 10 * time.Second
+
+	scopeName = "github.com/grafana/grafana/pkg/infra/metrics/otlpbridge"
+)
+
+// Bridge periodically gathers metrics and ships them to an OpenTelemetry
+// collector over OTLP/HTTP.
+type Bridge struct {
+	cfg    Config
+	client *http.Client
+
+	sendTotal   prometheus.Counter
+	sendErrors  prometheus.Counter
+	lastSuccess prometheus.Gauge
+}
+
+// NewBridge validates cfg, applying defaults, and returns a Bridge ready
+// to Run.
+func NewBridge(cfg *Config) (*Bridge, error) {
+	if cfg.Gatherer == nil {
+		return nil, fmt.Errorf("otlpbridge: Gatherer is required")
+	}
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("otlpbridge: Endpoint is required")
+	}
+
+	c := *cfg
+	if c.Interval <= 0 {
+		c.Interval = defaultInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+	if c.Logger == nil {
+		c.Logger = log.New("metrics.otlp")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.TLSConfig != nil {
+		transport.TLSClientConfig = c.TLSConfig
+	}
+
+	return &Bridge{
+		cfg:    c,
+		client: &http.Client{Timeout: c.Timeout, Transport: transport},
+		sendTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "grafana_metrics_bridge_otlp_send_total",
+			Help: "Number of OTLP/HTTP metric pushes attempted.",
+		}),
+		sendErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "grafana_metrics_bridge_otlp_send_errors_total",
+			Help: "Number of OTLP/HTTP metric pushes that failed.",
+		}),
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "grafana_metrics_bridge_otlp_last_success_timestamp_seconds",
+			Help: "Unix timestamp of the last successful OTLP/HTTP metric push.",
+		}),
+	}, nil
+}
+
+// Name implements metrics.PushBridge.
+func (b *Bridge) Name() string { return "otlp" }
+
+// Collectors returns the bridge's own health metrics, for registration
+// against the main registerer.
+func (b *Bridge) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{b.sendTotal, b.sendErrors, b.lastSuccess}
+}
+
+// Run pushes metrics on cfg.Interval until ctx is done.
+func (b *Bridge) Run(ctx context.Context) error {
+	ticker := time.NewTicker(b.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := b.push(ctx); err != nil {
+				b.cfg.Logger.Error("otlp push failed", "error", err)
+			}
+		}
+	}
+}
+
+func (b *Bridge) push(ctx context.Context) error {
+	b.sendTotal.Inc()
+
+	mf, err := b.cfg.Gatherer.Gather()
+	if err != nil {
+		b.sendErrors.Inc()
+		return fmt.Errorf("gather metrics: %w", err)
+	}
+
+	md := toMetrics(mf, b.cfg.Prefix, b.cfg.AllowList, b.cfg.DenyList)
+	data, err := pmetricotlp.NewExportRequestFromMetrics(md).MarshalProto()
+	if err != nil {
+		b.sendErrors.Inc()
+		return fmt.Errorf("marshal export request: %w", err)
+	}
+
+	url := strings.TrimRight(b.cfg.Endpoint, "/") + "/v1/metrics"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		b.sendErrors.Inc()
+		return fmt.Errorf("build request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	switch {
+	case b.cfg.BearerToken != "":
+		httpReq.Header.Set("Authorization", "Bearer "+b.cfg.BearerToken)
+	case b.cfg.BasicAuthUsername != "":
+		httpReq.SetBasicAuth(b.cfg.BasicAuthUsername, b.cfg.BasicAuthPassword)
+	}
+
+	resp, err := b.client.Do(httpReq)
+	if err != nil {
+		b.sendErrors.Inc()
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode/100 != 2 {
+		b.sendErrors.Inc()
+		return fmt.Errorf("otlp collector returned %s", resp.Status)
+	}
+
+	b.lastSuccess.SetToCurrentTime()
+	return nil
+}
+
+func included(name string, allow, deny []*regexp.Regexp) bool {
+	if len(allow) > 0 {
+		matched := false
+		for _, re := range allow {
+			if re.MatchString(name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, re := range deny {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func toMetrics(families []*dto.MetricFamily, prefix string, allow, deny []*regexp.Regexp) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	sm := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName(scopeName)
+
+	now := pcommon.NewTimestampFromTime(time.Now())
+	for _, fam := range families {
+		name := prefix + fam.GetName()
+		if !included(name, allow, deny) {
+			continue
+		}
+
+		// Histograms, summaries and untyped families aren't converted
+		// yet.
+		if fam.GetType() != dto.MetricType_COUNTER && fam.GetType() != dto.MetricType_GAUGE {
+			continue
+		}
+
+		metric := sm.Metrics().AppendEmpty()
+		metric.SetName(name)
+		metric.SetDescription(fam.GetHelp())
+
+		switch fam.GetType() {
+		case dto.MetricType_COUNTER:
+			sum := metric.SetEmptySum()
+			sum.SetIsMonotonic(true)
+			sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+			for _, m := range fam.GetMetric() {
+				dp := sum.DataPoints().AppendEmpty()
+				dp.SetDoubleValue(m.GetCounter().GetValue())
+				dp.SetTimestamp(now)
+				setAttributes(dp.Attributes(), m)
+			}
+		case dto.MetricType_GAUGE:
+			gauge := metric.SetEmptyGauge()
+			for _, m := range fam.GetMetric() {
+				dp := gauge.DataPoints().AppendEmpty()
+				dp.SetDoubleValue(m.GetGauge().GetValue())
+				dp.SetTimestamp(now)
+				setAttributes(dp.Attributes(), m)
+			}
+		}
+	}
+	return md
+}
+
+func setAttributes(attrs pcommon.Map, m *dto.Metric) {
+	for _, lp := range m.GetLabel() {
+		attrs.PutStr(lp.GetName(), lp.GetValue())
+	}
+}
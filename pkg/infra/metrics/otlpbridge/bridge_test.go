@@ -0,0 +1,84 @@
+package otlpbridge
+
+import (
+	"regexp"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestIncluded(t *testing.T) {
+	allow := []*regexp.Regexp{regexp.MustCompile(`^grafana_`)}
+	deny := []*regexp.Regexp{regexp.MustCompile(`_internal$`)}
+
+	cases := map[string]bool{
+		"grafana_requests_total":    true,
+		"grafana_requests_internal": false,
+		"other_metric":              false,
+	}
+	for name, want := range cases {
+		if got := included(name, allow, deny); got != want {
+			t.Errorf("included(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func newGaugeFamily(name string, value float64, labels ...*dto.LabelPair) *dto.MetricFamily {
+	help := name + " help"
+	typ := dto.MetricType_GAUGE
+	return &dto.MetricFamily{
+		Name: &name,
+		Help: &help,
+		Type: &typ,
+		Metric: []*dto.Metric{{
+			Label: labels,
+			Gauge: &dto.Gauge{Value: &value},
+		}},
+	}
+}
+
+func TestToMetricsAppliesPrefixAndFilters(t *testing.T) {
+	families := []*dto.MetricFamily{
+		newGaugeFamily("queue_depth", 3, &dto.LabelPair{Name: strPtr("queue"), Value: strPtr("alerts")}),
+		newGaugeFamily("excluded_depth", 9),
+	}
+
+	deny := []*regexp.Regexp{regexp.MustCompile(`^grafana_excluded_depth$`)}
+	md := toMetrics(families, "grafana_", nil, deny)
+
+	metrics := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	if got, want := metrics.Len(), 1; got != want {
+		t.Fatalf("got %d metrics, want %d", got, want)
+	}
+
+	metric := metrics.At(0)
+	if got, want := metric.Name(), "grafana_queue_depth"; got != want {
+		t.Errorf("Name() = %q, want %q", got, want)
+	}
+
+	dp := metric.Gauge().DataPoints().At(0)
+	if got, want := dp.DoubleValue(), 3.0; got != want {
+		t.Errorf("DoubleValue() = %v, want %v", got, want)
+	}
+	if got, want := dp.Attributes().AsRaw()["queue"], "alerts"; got != want {
+		t.Errorf("queue attribute = %v, want %v", got, want)
+	}
+}
+
+func TestToMetricsSkipsUnsupportedTypes(t *testing.T) {
+	name, help := "latency", "latency help"
+	typ := dto.MetricType_HISTOGRAM
+	fam := &dto.MetricFamily{
+		Name:   &name,
+		Help:   &help,
+		Type:   &typ,
+		Metric: []*dto.Metric{{Histogram: &dto.Histogram{}}},
+	}
+
+	md := toMetrics([]*dto.MetricFamily{fam}, "", nil, nil)
+	if got := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().Len(); got != 0 {
+		t.Errorf("expected histograms to be skipped, got %d metrics", got)
+	}
+}
+
+func strPtr(s string) *string { return &s }
@@ -0,0 +1,57 @@
+// Package otlpbridge ships a prometheus.Gatherer's snapshot to an
+// OpenTelemetry collector over OTLP/HTTP on an interval, as an
+// alternative to being scraped.
+package otlpbridge
+
+import (
+	"crypto/tls"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+// Config configures a Bridge.
+type Config struct {
+	// Endpoint is the collector's base URL; the bridge POSTs to
+	// "<Endpoint>/v1/metrics". Required.
+	Endpoint string
+
+	// Gatherer is the source of metrics to push. Required.
+	Gatherer prometheus.Gatherer
+
+	// Interval is how often Gatherer is scraped and pushed. Defaults to
+	// one minute.
+	Interval time.Duration
+	// Timeout bounds each push request. Defaults to ten seconds.
+	Timeout time.Duration
+
+	// Prefix, if set, is prepended to every metric name before it is
+	// pushed.
+	Prefix string
+
+	// AllowList, if non-empty, restricts pushed metrics to names
+	// matching at least one pattern. Applied after Prefix.
+	AllowList []*regexp.Regexp
+	// DenyList excludes metrics matching any pattern. Applied after
+	// AllowList.
+	DenyList []*regexp.Regexp
+
+	// BasicAuthUsername/BasicAuthPassword, set together, are sent as
+	// HTTP Basic auth. Ignored if BearerToken is set.
+	BasicAuthUsername string
+	BasicAuthPassword string
+	// BearerToken, if set, is sent as an HTTP Bearer Authorization
+	// header and takes precedence over basic auth.
+	BearerToken string
+
+	// TLSConfig configures the HTTP client's transport. A nil value
+	// uses Go's default TLS settings.
+	TLSConfig *tls.Config
+
+	// Logger receives bridge diagnostics. Defaults to
+	// log.New("metrics.otlp").
+	Logger log.Logger
+}
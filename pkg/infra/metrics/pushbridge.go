@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/infra/metrics/graphitebridge"
+)
+
+// PushBridge is a metrics bridge that periodically pushes a snapshot of
+// Grafana's metrics to an external system, as an alternative to being
+// scraped. InternalMetricsService runs every configured PushBridge as a
+// goroutine bound to its Run context.
+type PushBridge interface {
+	// Name identifies the bridge, e.g. in logs.
+	Name() string
+	// Run starts the bridge and blocks until ctx is done or an
+	// unrecoverable error occurs.
+	Run(ctx context.Context) error
+}
+
+// bridgeCollectors is implemented by bridges that expose their own health
+// metrics (send totals, send errors, last success timestamp, ...), so
+// InternalMetricsService can register them against the main registerer.
+type bridgeCollectors interface {
+	Collectors() []prometheus.Collector
+}
+
+// graphitePushBridge adapts the legacy graphitebridge.Bridge to the
+// PushBridge interface.
+type graphitePushBridge struct {
+	bridge *graphitebridge.Bridge
+}
+
+func newGraphitePushBridge(cfg *graphitebridge.Config) (*graphitePushBridge, error) {
+	bridge, err := graphitebridge.NewBridge(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &graphitePushBridge{bridge: bridge}, nil
+}
+
+func (g *graphitePushBridge) Name() string { return "graphite" }
+
+func (g *graphitePushBridge) Run(ctx context.Context) error {
+	g.bridge.Run(ctx)
+	return ctx.Err()
+}
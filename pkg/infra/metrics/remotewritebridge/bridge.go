@@ -0,0 +1,223 @@
+package remotewritebridge
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/prompb"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+const (
+	defaultInterval = time.Minute
+	defaultTimeout  = 10 * time.Second
+)
+
+// Bridge periodically gathers metrics and pushes them to a Prometheus
+// remote_write endpoint.
+type Bridge struct {
+	cfg    Config
+	client *http.Client
+
+	sendTotal   prometheus.Counter
+	sendErrors  prometheus.Counter
+	lastSuccess prometheus.Gauge
+}
+
+// NewBridge validates cfg, applying defaults, and returns a Bridge ready
+// to Run.
+func NewBridge(cfg *Config) (*Bridge, error) {
+	if cfg.Gatherer == nil {
+		return nil, fmt.Errorf("remotewritebridge: Gatherer is required")
+	}
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("remotewritebridge: URL is required")
+	}
+
+	c := *cfg
+	if c.Interval <= 0 {
+		c.Interval = defaultInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+	if c.Logger == nil {
+		c.Logger = log.New("metrics.remotewrite")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.TLSConfig != nil {
+		transport.TLSClientConfig = c.TLSConfig
+	}
+
+	return &Bridge{
+		cfg:    c,
+		client: &http.Client{Timeout: c.Timeout, Transport: transport},
+		sendTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "grafana_metrics_bridge_remote_write_send_total",
+			Help: "Number of remote_write pushes attempted.",
+		}),
+		sendErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "grafana_metrics_bridge_remote_write_send_errors_total",
+			Help: "Number of remote_write pushes that failed.",
+		}),
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "grafana_metrics_bridge_remote_write_last_success_timestamp_seconds",
+			Help: "Unix timestamp of the last successful remote_write push.",
+		}),
+	}, nil
+}
+
+// Name implements metrics.PushBridge.
+func (b *Bridge) Name() string { return "remote_write" }
+
+// Collectors returns the bridge's own health metrics, for registration
+// against the main registerer.
+func (b *Bridge) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{b.sendTotal, b.sendErrors, b.lastSuccess}
+}
+
+// Run pushes metrics on cfg.Interval until ctx is done.
+func (b *Bridge) Run(ctx context.Context) error {
+	ticker := time.NewTicker(b.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := b.push(ctx); err != nil {
+				b.cfg.Logger.Error("remote_write push failed", "error", err)
+			}
+		}
+	}
+}
+
+func (b *Bridge) push(ctx context.Context) error {
+	b.sendTotal.Inc()
+
+	mf, err := b.cfg.Gatherer.Gather()
+	if err != nil {
+		b.sendErrors.Inc()
+		return fmt.Errorf("gather metrics: %w", err)
+	}
+
+	req := toWriteRequest(mf, b.cfg.Prefix, b.cfg.AllowList, b.cfg.DenyList)
+	data, err := proto.Marshal(req)
+	if err != nil {
+		b.sendErrors.Inc()
+		return fmt.Errorf("marshal write request: %w", err)
+	}
+	compressed := snappy.Encode(nil, data)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, b.cfg.URL, bytes.NewReader(compressed))
+	if err != nil {
+		b.sendErrors.Inc()
+		return fmt.Errorf("build request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("Content-Encoding", "snappy")
+	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	switch {
+	case b.cfg.BearerToken != "":
+		httpReq.Header.Set("Authorization", "Bearer "+b.cfg.BearerToken)
+	case b.cfg.BasicAuthUsername != "":
+		httpReq.SetBasicAuth(b.cfg.BasicAuthUsername, b.cfg.BasicAuthPassword)
+	}
+
+	resp, err := b.client.Do(httpReq)
+	if err != nil {
+		b.sendErrors.Inc()
+		return fmt.Errorf("send request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode/100 != 2 {
+		b.sendErrors.Inc()
+		return fmt.Errorf("remote_write endpoint returned %s", resp.Status)
+	}
+
+	b.lastSuccess.SetToCurrentTime()
+	return nil
+}
+
+func included(name string, allow, deny []*regexp.Regexp) bool {
+	if len(allow) > 0 {
+		matched := false
+		for _, re := range allow {
+			if re.MatchString(name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, re := range deny {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func toWriteRequest(families []*dto.MetricFamily, prefix string, allow, deny []*regexp.Regexp) *prompb.WriteRequest {
+	req := &prompb.WriteRequest{}
+	for _, fam := range families {
+		name := prefix + fam.GetName()
+		if !included(name, allow, deny) {
+			continue
+		}
+		for _, m := range fam.GetMetric() {
+			value, ok := valueOf(fam.GetType(), m)
+			if !ok {
+				// Histograms and summaries need multiple series per
+				// metric and aren't converted yet.
+				continue
+			}
+
+			labels := make([]prompb.Label, 0, len(m.GetLabel())+1)
+			labels = append(labels, prompb.Label{Name: "__name__", Value: name})
+			for _, lp := range m.GetLabel() {
+				labels = append(labels, prompb.Label{Name: lp.GetName(), Value: lp.GetValue()})
+			}
+
+			req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+				Labels:  labels,
+				Samples: []prompb.Sample{{Value: value, Timestamp: timestampMillis(m)}},
+			})
+		}
+	}
+	return req
+}
+
+func valueOf(t dto.MetricType, m *dto.Metric) (float64, bool) {
+	switch t {
+	case dto.MetricType_COUNTER:
+		return m.GetCounter().GetValue(), true
+	case dto.MetricType_GAUGE:
+		return m.GetGauge().GetValue(), true
+	case dto.MetricType_UNTYPED:
+		return m.GetUntyped().GetValue(), true
+	default:
+		return 0, false
+	}
+}
+
+func timestampMillis(m *dto.Metric) int64 {
+	if ts := m.GetTimestampMs(); ts != 0 {
+		return ts
+	}
+	return time.Now().UnixMilli()
+}
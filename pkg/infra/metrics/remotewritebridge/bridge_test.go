@@ -0,0 +1,94 @@
+package remotewritebridge
+
+import (
+	"regexp"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestIncluded(t *testing.T) {
+	allow := []*regexp.Regexp{regexp.MustCompile(`^grafana_`)}
+	deny := []*regexp.Regexp{regexp.MustCompile(`_internal$`)}
+
+	cases := map[string]bool{
+		"grafana_requests_total":    true,
+		"grafana_requests_internal": false,
+		"other_metric":              false,
+	}
+	for name, want := range cases {
+		if got := included(name, allow, deny); got != want {
+			t.Errorf("included(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestIncludedNoAllowListIncludesEverythingExceptDenied(t *testing.T) {
+	deny := []*regexp.Regexp{regexp.MustCompile(`^go_`)}
+	if !included("grafana_requests_total", nil, deny) {
+		t.Error("expected a non-denied name to be included with no allow list")
+	}
+	if included("go_goroutines", nil, deny) {
+		t.Error("expected a denied name to be excluded")
+	}
+}
+
+func newCounterFamily(name string, value float64, labels ...*dto.LabelPair) *dto.MetricFamily {
+	help := name + " help"
+	typ := dto.MetricType_COUNTER
+	return &dto.MetricFamily{
+		Name: &name,
+		Help: &help,
+		Type: &typ,
+		Metric: []*dto.Metric{{
+			Label:   labels,
+			Counter: &dto.Counter{Value: &value},
+		}},
+	}
+}
+
+func TestToWriteRequestAppliesPrefixAndFilters(t *testing.T) {
+	families := []*dto.MetricFamily{
+		newCounterFamily("requests_total", 1, &dto.LabelPair{Name: strPtr("job"), Value: strPtr("grafana")}),
+		newCounterFamily("excluded_total", 2),
+	}
+
+	deny := []*regexp.Regexp{regexp.MustCompile(`^grafana_excluded_total$`)}
+	req := toWriteRequest(families, "grafana_", nil, deny)
+
+	if got, want := len(req.Timeseries), 1; got != want {
+		t.Fatalf("got %d timeseries, want %d", got, want)
+	}
+	ts := req.Timeseries[0]
+
+	var name string
+	for _, lp := range ts.Labels {
+		if lp.Name == "__name__" {
+			name = lp.Value
+		}
+	}
+	if got, want := name, "grafana_requests_total"; got != want {
+		t.Errorf("__name__ = %q, want %q", got, want)
+	}
+	if got, want := ts.Samples[0].Value, 1.0; got != want {
+		t.Errorf("sample value = %v, want %v", got, want)
+	}
+}
+
+func TestToWriteRequestSkipsUnsupportedTypes(t *testing.T) {
+	name, help := "latency", "latency help"
+	typ := dto.MetricType_HISTOGRAM
+	fam := &dto.MetricFamily{
+		Name:   &name,
+		Help:   &help,
+		Type:   &typ,
+		Metric: []*dto.Metric{{Histogram: &dto.Histogram{}}},
+	}
+
+	req := toWriteRequest([]*dto.MetricFamily{fam}, "", nil, nil)
+	if len(req.Timeseries) != 0 {
+		t.Errorf("expected histograms to be skipped, got %d timeseries", len(req.Timeseries))
+	}
+}
+
+func strPtr(s string) *string { return &s }
@@ -0,0 +1,57 @@
+// Package remotewritebridge pushes a prometheus.Gatherer's snapshot to a
+// Prometheus remote_write compatible endpoint on an interval, as an
+// alternative to being scraped.
+package remotewritebridge
+
+import (
+	"crypto/tls"
+	"regexp"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+// Config configures a Bridge.
+type Config struct {
+	// URL is the remote_write endpoint to POST to, e.g.
+	// "https://prometheus.example.com/api/v1/write". Required.
+	URL string
+
+	// Gatherer is the source of metrics to push. Required.
+	Gatherer prometheus.Gatherer
+
+	// Interval is how often Gatherer is scraped and pushed. Defaults to
+	// one minute.
+	Interval time.Duration
+	// Timeout bounds each push request. Defaults to ten seconds.
+	Timeout time.Duration
+
+	// Prefix, if set, is prepended to every metric name before it is
+	// pushed.
+	Prefix string
+
+	// AllowList, if non-empty, restricts pushed metrics to names
+	// matching at least one pattern. Applied after Prefix.
+	AllowList []*regexp.Regexp
+	// DenyList excludes metrics matching any pattern. Applied after
+	// AllowList.
+	DenyList []*regexp.Regexp
+
+	// BasicAuthUsername/BasicAuthPassword, set together, are sent as
+	// HTTP Basic auth. Ignored if BearerToken is set.
+	BasicAuthUsername string
+	BasicAuthPassword string
+	// BearerToken, if set, is sent as an HTTP Bearer Authorization
+	// header and takes precedence over basic auth.
+	BearerToken string
+
+	// TLSConfig configures the HTTP client's transport. A nil value
+	// uses Go's default TLS settings.
+	TLSConfig *tls.Config
+
+	// Logger receives bridge diagnostics. Defaults to
+	// log.New("metrics.remotewrite").
+	Logger log.Logger
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	defaultScrapeTimeout     = 10 * time.Second
+	defaultScrapeConcurrency = 4
+)
+
+// CollectorFactory builds a prometheus.Collector scoped to a single HTTP
+// request, from that request's query parameters.
+type CollectorFactory func(params url.Values) (prometheus.Collector, error)
+
+var (
+	scrapeRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "grafana_scrape_requests_total",
+		Help: "Number of per-request scrape handler invocations, by outcome.",
+	}, []string{"status"})
+
+	scrapeDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "grafana_scrape_duration_seconds",
+		Help: "Duration of per-request scrape handler invocations, by factory.",
+	}, []string{"factory"})
+)
+
+// registerScrapeMetrics registers the shared scrape handler counters
+// against reg. It is idempotent, since RegisterScrapeEndpoint may be
+// called once per endpoint against the same registerer.
+func registerScrapeMetrics(reg prometheus.Registerer) error {
+	for _, c := range []prometheus.Collector{scrapeRequestsTotal, scrapeDurationSeconds} {
+		if err := reg.Register(c); err != nil {
+			var are prometheus.AlreadyRegisteredError
+			if !errors.As(err, &are) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ScrapeHandler implements the "one registry per request" pattern for
+// expensive, on-demand collectors: each request gets a short-lived
+// prometheus.Registry holding a single Collector built from that
+// request's parameters, is served via promhttp, and the registry is
+// discarded afterwards.
+type ScrapeHandler struct {
+	name    string
+	factory CollectorFactory
+	timeout time.Duration
+	sem     chan struct{}
+}
+
+// NewScrapeHandler returns an http.Handler that, on each request, builds a
+// fresh Collector via factory, serves it through a short-lived registry,
+// and discards it. timeout bounds each scrape; maxConcurrent bounds the
+// number of scrapes in flight, responding 429 to requests beyond that.
+// name identifies the handler in the grafana_scrape_duration_seconds
+// metric, which is registered against reg.
+func NewScrapeHandler(name string, factory CollectorFactory, timeout time.Duration, maxConcurrent int, reg prometheus.Registerer) (*ScrapeHandler, error) {
+	if err := registerScrapeMetrics(reg); err != nil {
+		return nil, fmt.Errorf("metrics: failed to register scrape handler metrics: %w", err)
+	}
+
+	return &ScrapeHandler{
+		name:    name,
+		factory: factory,
+		timeout: timeout,
+		sem:     make(chan struct{}, maxConcurrent),
+	}, nil
+}
+
+func (h *ScrapeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	select {
+	case h.sem <- struct{}{}:
+		defer func() { <-h.sem }()
+	default:
+		scrapeRequestsTotal.WithLabelValues("overflow").Inc()
+		http.Error(w, "too many concurrent scrapes", http.StatusTooManyRequests)
+		return
+	}
+
+	start := time.Now()
+
+	collector, err := h.factory(r.URL.Query())
+	if err != nil {
+		scrapeRequestsTotal.WithLabelValues("error").Inc()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(collector); err != nil {
+		scrapeRequestsTotal.WithLabelValues("error").Inc()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rec := &statusRecordingWriter{ResponseWriter: w, status: http.StatusOK}
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{
+		Timeout:       h.timeout,
+		ErrorHandling: promhttp.HTTPErrorOnError,
+	}).ServeHTTP(rec, r)
+
+	status := "ok"
+	if rec.status >= http.StatusBadRequest {
+		status = "error"
+	}
+	scrapeRequestsTotal.WithLabelValues(status).Inc()
+	scrapeDurationSeconds.WithLabelValues(h.name).Observe(time.Since(start).Seconds())
+}
+
+// statusRecordingWriter captures the status code promhttp's handler
+// writes, so ServeHTTP can tell a successful scrape from one that failed
+// mid-collection (a Collector error or a scrape timeout), which promhttp
+// only ever surfaces as the HTTP response it wrote.
+type statusRecordingWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecordingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
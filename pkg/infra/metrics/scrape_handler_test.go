@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestScrapeHandlerServesCollectorOutput(t *testing.T) {
+	factory := func(url.Values) (prometheus.Collector, error) {
+		c := prometheus.NewCounter(prometheus.CounterOpts{Name: "plugin_scrapes_total", Help: "help"})
+		c.Inc()
+		return c, nil
+	}
+	h, err := NewScrapeHandler("plugin", factory, defaultScrapeTimeout, defaultScrapeConcurrency, prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("NewScrapeHandler: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics/plugin", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if !strings.Contains(rec.Body.String(), "plugin_scrapes_total") {
+		t.Errorf("response body missing expected metric, got: %s", rec.Body.String())
+	}
+}
+
+func TestScrapeHandlerFactoryErrorReturnsBadRequest(t *testing.T) {
+	factory := func(url.Values) (prometheus.Collector, error) {
+		return nil, errors.New("boom")
+	}
+	h, err := NewScrapeHandler("plugin", factory, defaultScrapeTimeout, defaultScrapeConcurrency, prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("NewScrapeHandler: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics/plugin", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestScrapeHandlerConcurrencyLimitReturnsTooManyRequests(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	factory := func(url.Values) (prometheus.Collector, error) {
+		return &blockingCollector{started: started, release: release}, nil
+	}
+
+	h, err := NewScrapeHandler("plugin", factory, defaultScrapeTimeout, 1, prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("NewScrapeHandler: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := httptest.NewRequest(http.MethodGet, "/metrics/plugin", nil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-started
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics/plugin", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+
+	close(release)
+	<-done
+}
+
+// blockingCollector is a prometheus.Collector whose Collect blocks until
+// release is closed, used to hold a ScrapeHandler slot open so a second,
+// concurrent request can observe the concurrency limit.
+type blockingCollector struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (b *blockingCollector) Collect(ch chan<- prometheus.Metric) {
+	select {
+	case b.started <- struct{}{}:
+	default:
+	}
+	<-b.release
+}
@@ -2,11 +2,10 @@ package metrics
 
 import (
 	"context"
-	"errors"
-	"regexp"
+	"fmt"
+	"net/http"
 
 	"github.com/prometheus/client_golang/prometheus"
-	dto "github.com/prometheus/client_model/go"
 	"k8s.io/component-base/metrics/legacyregistry"
 
 	"github.com/grafana/grafana/pkg/infra/log"
@@ -30,27 +29,78 @@ func ProvideService(cfg *setting.Cfg, reg prometheus.Registerer) (*InternalMetri
 	initFrontendMetrics(reg)
 
 	s := &InternalMetricsService{
-		Cfg: cfg,
+		Cfg:         cfg,
+		reg:         reg,
+		scrapeMux:   http.NewServeMux(),
+		scrapePaths: make(map[string]struct{}),
 	}
 	return s, s.readSettings()
 }
 
 type InternalMetricsService struct {
 	Cfg *setting.Cfg
+	reg prometheus.Registerer
 
 	intervalSeconds int64
+	gatherer        prometheus.Gatherer
 	graphiteCfg     *graphitebridge.Config
+	bridges         []PushBridge
+
+	// scrapeMux collects the endpoints registered via
+	// RegisterScrapeEndpoint. Handler returns it so the core HTTP server
+	// can mount it alongside its other routes. scrapePaths tracks the same
+	// set of paths so a duplicate Register can be rejected with an error
+	// instead of panicking, which is what http.ServeMux.Handle does.
+	scrapeMux   *http.ServeMux
+	scrapePaths map[string]struct{}
+}
+
+// RegisterScrapeEndpoint registers a ScrapeHandler for path, built from
+// factory with sane timeout and concurrency defaults, so feature owners
+// can add endpoints like "/metrics/plugin?id=foo" exposing a
+// parameterized, on-demand collector. It returns an error instead of
+// panicking if path was already registered. The core HTTP server must
+// mount Handler() onto its own mux for these endpoints to be reachable;
+// this service does not run a listener of its own.
+func (im *InternalMetricsService) RegisterScrapeEndpoint(path string, factory CollectorFactory) error {
+	if _, registered := im.scrapePaths[path]; registered {
+		return fmt.Errorf("metrics: scrape endpoint %q already registered", path)
+	}
+
+	h, err := NewScrapeHandler(path, factory, defaultScrapeTimeout, defaultScrapeConcurrency, im.reg)
+	if err != nil {
+		return fmt.Errorf("metrics: failed to register scrape endpoint %q: %w", path, err)
+	}
+
+	im.scrapeMux.Handle(path, h)
+	im.scrapePaths[path] = struct{}{}
+	return nil
+}
+
+// Handler returns the http.Handler serving every endpoint registered via
+// RegisterScrapeEndpoint. Callers mount it onto the core HTTP server's
+// existing mux, e.g. under "/metrics/", rather than exposing it on a
+// separate listener.
+func (im *InternalMetricsService) Handler() http.Handler {
+	return im.scrapeMux
 }
 
 func (im *InternalMetricsService) Run(ctx context.Context) error {
-	// Start Graphite Bridge
-	if im.graphiteCfg != nil {
-		bridge, err := graphitebridge.NewBridge(im.graphiteCfg)
-		if err != nil {
-			metricsLogger.Error("failed to create graphite bridge", "error", err)
-		} else {
-			go bridge.Run(ctx)
+	for _, bridge := range im.bridges {
+		bridge := bridge
+		if bc, ok := bridge.(bridgeCollectors); ok {
+			for _, c := range bc.Collectors() {
+				if err := im.reg.Register(c); err != nil {
+					metricsLogger.Error("failed to register bridge metrics", "bridge", bridge.Name(), "error", err)
+				}
+			}
 		}
+
+		go func() {
+			if err := bridge.Run(ctx); err != nil && ctx.Err() == nil {
+				metricsLogger.Error("push bridge stopped unexpectedly", "bridge", bridge.Name(), "error", err)
+			}
+		}()
 	}
 
 	MInstanceStart.Inc()
@@ -66,11 +116,32 @@ func ProvideRegisterer(cfg *setting.Cfg) prometheus.Registerer {
 	return prometheus.DefaultRegisterer
 }
 
+// internalCache holds expensive, infrequently-changing internal metrics
+// (datasource inventory, plugin catalog, object counts, ...) pushed by
+// producers out-of-band. See ProvideInternalCache.
+var internalCache = NewCachedGatherer()
+
+// ProvideInternalCache returns the process-wide CachedGatherer composed
+// into the exported metrics output by ProvideGatherer. Producers push
+// snapshots into it via StartUpdateSession instead of implementing a
+// prometheus.Collector that would otherwise block scrapes.
+func ProvideInternalCache() *CachedGatherer {
+	return internalCache
+}
+
 func ProvideGatherer(cfg *setting.Cfg) prometheus.Gatherer {
-	if cfg.IsFeatureToggleEnabled(featuremgmt.FlagGrafanaAPIServer) {
-		return newAddPrefixWrapper(legacyregistry.DefaultGatherer)
+	if !cfg.IsFeatureToggleEnabled(featuremgmt.FlagGrafanaAPIServer) {
+		return prometheus.DefaultGatherer
+	}
+
+	g := NewPrefixGatherer().WithExclusions(readExclusionConfig(cfg))
+	if err := g.Register("grafana", legacyregistry.DefaultGatherer); err != nil {
+		metricsLogger.Error("failed to register legacy gatherer", "error", err)
 	}
-	return prometheus.DefaultGatherer
+	if err := g.Register("cached", AsGatherer(internalCache)); err != nil {
+		metricsLogger.Error("failed to register cached gatherer", "error", err)
+	}
+	return g
 }
 
 func ProvideRegistererForTest() prometheus.Registerer {
@@ -82,43 +153,3 @@ func ProvideGathererForTest(reg prometheus.Registerer) prometheus.Gatherer {
 	// is a *prometheus.Registry, so it also implements prometheus.Gatherer
 	return reg.(*prometheus.Registry)
 }
-
-var _ prometheus.Gatherer = (*addPrefixWrapper)(nil)
-
-// addPrefixWrapper wraps a prometheus.Gatherer, and ensures that all metric names are prefixed with `grafana_`.
-// metrics with the prefix `grafana_` or `go_` are not modified.
-type addPrefixWrapper struct {
-	orig prometheus.Gatherer
-	reg  *regexp.Regexp
-}
-
-func newAddPrefixWrapper(orig prometheus.Gatherer) *addPrefixWrapper {
-	return &addPrefixWrapper{
-		orig: orig,
-		reg:  regexp.MustCompile("^((?:grafana_|go_).*)"),
-	}
-}
-
-func (g *addPrefixWrapper) Gather() ([]*dto.MetricFamily, error) {
-	mf, err := g.orig.Gather()
-	if err != nil {
-		return nil, err
-	}
-
-	names := make(map[string]struct{})
-
-	for i := 0; i < len(mf); i++ {
-		m := mf[i]
-		if m.Name != nil && !g.reg.MatchString(*m.Name) {
-			*m.Name = "grafana_" + *m.Name
-			// since we are modifying the name, we need to check for duplicates in the gatherer
-			if _, exists := names[*m.Name]; exists {
-				return nil, errors.New("duplicate metric name: " + *m.Name)
-			}
-		}
-		// keep track of names to detect duplicates
-		names[*m.Name] = struct{}{}
-	}
-
-	return mf, nil
-}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newTestService() *InternalMetricsService {
+	return &InternalMetricsService{
+		reg:         prometheus.NewRegistry(),
+		scrapeMux:   http.NewServeMux(),
+		scrapePaths: make(map[string]struct{}),
+	}
+}
+
+func TestRegisterScrapeEndpointRejectsDuplicatePath(t *testing.T) {
+	im := newTestService()
+	factory := func(url.Values) (prometheus.Collector, error) {
+		return prometheus.NewCounter(prometheus.CounterOpts{Name: "c"}), nil
+	}
+
+	if err := im.RegisterScrapeEndpoint("/metrics/plugin", factory); err != nil {
+		t.Fatalf("first RegisterScrapeEndpoint: %v", err)
+	}
+
+	if err := im.RegisterScrapeEndpoint("/metrics/plugin", factory); err == nil {
+		t.Fatal("expected a second RegisterScrapeEndpoint for the same path to return an error, got nil")
+	}
+}
+
+func TestHandlerServesRegisteredEndpoints(t *testing.T) {
+	im := newTestService()
+	factory := func(url.Values) (prometheus.Collector, error) {
+		c := prometheus.NewCounter(prometheus.CounterOpts{Name: "plugin_scrapes_total"})
+		c.Inc()
+		return c, nil
+	}
+
+	if err := im.RegisterScrapeEndpoint("/metrics/plugin", factory); err != nil {
+		t.Fatalf("RegisterScrapeEndpoint: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics/plugin", nil)
+	rec := httptest.NewRecorder()
+	im.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Handler: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
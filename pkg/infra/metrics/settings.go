@@ -0,0 +1,248 @@
+package metrics
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+
+	"github.com/grafana/grafana/pkg/infra/metrics/graphitebridge"
+	"github.com/grafana/grafana/pkg/infra/metrics/otlpbridge"
+	"github.com/grafana/grafana/pkg/infra/metrics/remotewritebridge"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// readExclusionConfig builds the set of metric families that must be
+// passed through ProvideGatherer's PrefixGatherer verbatim, combining
+// DefaultExclusionConfig with the [metrics] passthrough_metrics,
+// passthrough_metrics_regex, strip_const_labels_on_passthrough and
+// const_label_names keys.
+func readExclusionConfig(cfg *setting.Cfg) ExclusionConfig {
+	section := cfg.Raw.Section("metrics")
+	excl := DefaultExclusionConfig()
+
+	for _, name := range strings.Split(section.Key("passthrough_metrics").String(), ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			excl.ExactNames = append(excl.ExactNames, name)
+		}
+	}
+
+	patterns, err := parseRegexList(section.Key("passthrough_metrics_regex").String())
+	if err != nil {
+		metricsLogger.Error("invalid passthrough_metrics_regex", "error", err)
+	} else {
+		excl.Patterns = append(excl.Patterns, patterns...)
+	}
+
+	excl.StripConstLabels = section.Key("strip_const_labels_on_passthrough").MustBool(false)
+
+	// prometheus.Registerer exposes no way to introspect the ConstLabels an
+	// operator may have wrapped it with (prometheus.WrapRegistererWith), so
+	// the label names to strip must be configured explicitly here rather
+	// than discovered from the registry.
+	for _, name := range strings.Split(section.Key("const_label_names").String(), ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			excl.ConstLabelNames = append(excl.ConstLabelNames, name)
+		}
+	}
+
+	return excl
+}
+
+func (im *InternalMetricsService) readSettings() error {
+	section := im.Cfg.Raw.Section("metrics")
+	im.intervalSeconds = section.Key("interval_seconds").MustInt64(10)
+
+	// Build the composed gatherer once and share it across every bridge:
+	// each bridge otherwise gathers from the same underlying
+	// legacyregistry/internalCache independently, on its own ticker.
+	im.gatherer = ProvideGatherer(im.Cfg)
+
+	if err := im.readGraphiteSettings(); err != nil {
+		return err
+	}
+	if im.graphiteCfg != nil {
+		bridge, err := newGraphitePushBridge(im.graphiteCfg)
+		if err != nil {
+			metricsLogger.Error("failed to create graphite bridge", "error", err)
+		} else {
+			im.bridges = append(im.bridges, bridge)
+		}
+	}
+
+	if err := im.readRemoteWriteSettings(); err != nil {
+		return err
+	}
+	if err := im.readOTLPSettings(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (im *InternalMetricsService) readGraphiteSettings() error {
+	section := im.Cfg.Raw.Section("metrics.graphite")
+	address := section.Key("address").String()
+	if address == "" {
+		return nil
+	}
+
+	im.graphiteCfg = &graphitebridge.Config{
+		URL:      address,
+		Prefix:   section.Key("prefix").MustString("prod.grafana.%(instance_name)s."),
+		Interval: time.Duration(im.intervalSeconds) * time.Second,
+		Timeout:  10 * time.Second,
+		Gatherer: im.gatherer,
+		Logger:   &logWrapper{logger: metricsLogger},
+	}
+	return nil
+}
+
+// parseRegexList splits a comma-separated list of regex patterns from an
+// ini value, compiling each one. An empty value returns a nil slice.
+func parseRegexList(value string) ([]*regexp.Regexp, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(value, ",")
+	out := make([]*regexp.Regexp, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+// readTLSConfig builds an optional *tls.Config for a push bridge's HTTP
+// client from tls_cert_file/tls_key_file (client certificate),
+// tls_ca_cert_file (custom CA) and tls_skip_verify. It returns a nil
+// *tls.Config, and no error, when none of those keys are set.
+func readTLSConfig(section *ini.Section) (*tls.Config, error) {
+	certFile := section.Key("tls_cert_file").String()
+	keyFile := section.Key("tls_key_file").String()
+	caCertFile := section.Key("tls_ca_cert_file").String()
+	skipVerify := section.Key("tls_skip_verify").MustBool(false)
+
+	if certFile == "" && keyFile == "" && caCertFile == "" && !skipVerify {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{InsecureSkipVerify: skipVerify}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("metrics: failed to load tls_cert_file/tls_key_file: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if caCertFile != "" {
+		pem, err := os.ReadFile(caCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("metrics: failed to read tls_ca_cert_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("metrics: tls_ca_cert_file %q contains no valid certificates", caCertFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	return cfg, nil
+}
+
+func (im *InternalMetricsService) readRemoteWriteSettings() error {
+	section := im.Cfg.Raw.Section("metrics.remote_write")
+	if !section.Key("enabled").MustBool(false) {
+		return nil
+	}
+
+	allow, err := parseRegexList(section.Key("allow_list").String())
+	if err != nil {
+		return err
+	}
+	deny, err := parseRegexList(section.Key("deny_list").String())
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := readTLSConfig(section)
+	if err != nil {
+		return err
+	}
+
+	bridge, err := remotewritebridge.NewBridge(&remotewritebridge.Config{
+		URL:               section.Key("url").String(),
+		Gatherer:          im.gatherer,
+		Interval:          time.Duration(section.Key("interval_seconds").MustInt64(60)) * time.Second,
+		Prefix:            section.Key("prefix").String(),
+		AllowList:         allow,
+		DenyList:          deny,
+		BasicAuthUsername: section.Key("basic_auth_username").String(),
+		BasicAuthPassword: section.Key("basic_auth_password").String(),
+		BearerToken:       section.Key("bearer_token").String(),
+		TLSConfig:         tlsConfig,
+	})
+	if err != nil {
+		metricsLogger.Error("failed to create remote_write bridge", "error", err)
+		return nil
+	}
+
+	im.bridges = append(im.bridges, bridge)
+	return nil
+}
+
+func (im *InternalMetricsService) readOTLPSettings() error {
+	section := im.Cfg.Raw.Section("metrics.otlp")
+	if !section.Key("enabled").MustBool(false) {
+		return nil
+	}
+
+	allow, err := parseRegexList(section.Key("allow_list").String())
+	if err != nil {
+		return err
+	}
+	deny, err := parseRegexList(section.Key("deny_list").String())
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := readTLSConfig(section)
+	if err != nil {
+		return err
+	}
+
+	bridge, err := otlpbridge.NewBridge(&otlpbridge.Config{
+		Endpoint:          section.Key("endpoint").String(),
+		Gatherer:          im.gatherer,
+		Interval:          time.Duration(section.Key("interval_seconds").MustInt64(60)) * time.Second,
+		Prefix:            section.Key("prefix").String(),
+		AllowList:         allow,
+		DenyList:          deny,
+		BasicAuthUsername: section.Key("basic_auth_username").String(),
+		BasicAuthPassword: section.Key("basic_auth_password").String(),
+		BearerToken:       section.Key("bearer_token").String(),
+		TLSConfig:         tlsConfig,
+	})
+	if err != nil {
+		metricsLogger.Error("failed to create otlp bridge", "error", err)
+		return nil
+	}
+
+	im.bridges = append(im.bridges, bridge)
+	return nil
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func testSection(t *testing.T, keys map[string]string) *ini.Section {
+	t.Helper()
+	f := ini.Empty()
+	section, err := f.NewSection("test")
+	if err != nil {
+		t.Fatalf("NewSection: %v", err)
+	}
+	for k, v := range keys {
+		section.NewKey(k, v)
+	}
+	return section
+}
+
+func TestReadTLSConfigNilWhenUnconfigured(t *testing.T) {
+	cfg, err := readTLSConfig(testSection(t, nil))
+	if err != nil {
+		t.Fatalf("readTLSConfig: %v", err)
+	}
+	if cfg != nil {
+		t.Errorf("expected a nil TLS config when no tls_* keys are set, got %+v", cfg)
+	}
+}
+
+func TestReadTLSConfigSkipVerify(t *testing.T) {
+	cfg, err := readTLSConfig(testSection(t, map[string]string{"tls_skip_verify": "true"}))
+	if err != nil {
+		t.Fatalf("readTLSConfig: %v", err)
+	}
+	if cfg == nil || !cfg.InsecureSkipVerify {
+		t.Errorf("expected InsecureSkipVerify=true, got %+v", cfg)
+	}
+}
+
+func TestReadTLSConfigLoadsCAFromFile(t *testing.T) {
+	caPEM := []byte(`-----BEGIN CERTIFICATE-----
+MIIBVjCB/aADAgECAgEBMAoGCCqGSM49BAMCMBIxEDAOBgNVBAoTB1Rlc3QgQ0Ew
+IBcNNzAwMTAxMDAwMDAwWhgPMjA3MDAxMDEwMDAwMDBaMBIxEDAOBgNVBAoTB1Rl
+c3QgQ0EwWTATBgcqhkjOPQIBBggqhkjOPQMBBwNCAASPd51mO1HyUDvsSP4j1hWV
+J9KHZJED+Fvil5+zfyI5u3ul8O/7iXjfuSa5J/BhunEGgwuv0/EnYIIZHKpeWto9
+o0IwQDAOBgNVHQ8BAf8EBAMCAoQwDwYDVR0TAQH/BAUwAwEB/zAdBgNVHQ4EFgQU
+PwPmL+BOWnHD6nutvpv5lZBTcZgwCgYIKoZIzj0EAwIDSAAwRQIgaEO4jVLR3uGS
+eUEg8fq3EsjWdO2/gEgzIPKubKuJIHgCIQDtioe2hS/uThBae4Zp/ngzwjE8tdF3
+Cs4Cel+isSTb9g==
+-----END CERTIFICATE-----`)
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, caPEM, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	cfg, err := readTLSConfig(testSection(t, map[string]string{"tls_ca_cert_file": path}))
+	if err != nil {
+		t.Fatalf("readTLSConfig: %v", err)
+	}
+	if cfg == nil || cfg.RootCAs == nil {
+		t.Errorf("expected a populated RootCAs pool, got %+v", cfg)
+	}
+}
+
+func TestReadTLSConfigErrorsOnMissingCAFile(t *testing.T) {
+	if _, err := readTLSConfig(testSection(t, map[string]string{"tls_ca_cert_file": "/does/not/exist.pem"})); err == nil {
+		t.Fatal("expected an error for a missing tls_ca_cert_file, got nil")
+	}
+}
+
+func TestParseRegexList(t *testing.T) {
+	patterns, err := parseRegexList("^foo_, ^bar_")
+	if err != nil {
+		t.Fatalf("parseRegexList: %v", err)
+	}
+	if len(patterns) != 2 {
+		t.Fatalf("got %d patterns, want 2", len(patterns))
+	}
+	if !patterns[0].MatchString("foo_total") || !patterns[1].MatchString("bar_total") {
+		t.Errorf("compiled patterns did not match expected names: %v", patterns)
+	}
+}
+
+func TestParseRegexListEmpty(t *testing.T) {
+	patterns, err := parseRegexList("")
+	if err != nil {
+		t.Fatalf("parseRegexList: %v", err)
+	}
+	if patterns != nil {
+		t.Errorf("expected a nil slice for an empty value, got %v", patterns)
+	}
+}
+
+func TestParseRegexListInvalidPattern(t *testing.T) {
+	if _, err := parseRegexList("("); err == nil {
+		t.Fatal("expected an error for an invalid regex, got nil")
+	}
+}